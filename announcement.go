@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"text/template"
+
+	"github.com/samuel/go-zookeeper/zk"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+// announcementTemplateData is the context exposed to a ZkAnnouncement's
+// PathTemplate.
+type announcementTemplateData struct {
+	Namespace string
+	Name      string
+}
+
+// matchingPolicies returns the ZkAnnouncement policies in policies whose
+// Selector matches service's labels.
+func matchingPolicies(policies []*ZkAnnouncement, service *v1.Service) []*ZkAnnouncement {
+	var matched []*ZkAnnouncement
+	for _, policy := range policies {
+		selector, err := metav1.LabelSelectorAsSelector(&policy.Spec.Selector)
+		if err != nil {
+			log.Debugf("policy %s/%s has an invalid selector: %v", policy.Namespace, policy.Name, err)
+			continue
+		}
+		if selector.Matches(labelSet(service.Labels)) {
+			matched = append(matched, policy)
+		}
+	}
+	return matched
+}
+
+// countAnnouncedServices returns how many of services match policy's
+// Selector, i.e. how many Services policy is currently responsible for
+// announcing -- the count reported via its status subresource.
+func countAnnouncedServices(services []*v1.Service, policy *ZkAnnouncement) (int, error) {
+	selector, err := metav1.LabelSelectorAsSelector(&policy.Spec.Selector)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, service := range services {
+		if selector.Matches(labelSet(service.Labels)) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// renderPolicyPath executes policy.Spec.PathTemplate against service,
+// producing the znode path the service should be announced under.
+func renderPolicyPath(policy *ZkAnnouncement, service *v1.Service) (string, error) {
+	tmpl, err := template.New(policy.Name).Parse(policy.Spec.PathTemplate)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	data := announcementTemplateData{
+		Namespace: service.Namespace,
+		Name:      service.Name,
+	}
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// renderFilteredPayload renders service/endpoints' full payload and
+// trims it down to fields, the json keys listed in a ZkAnnouncement's
+// PayloadFields. An empty fields returns the full payload unfiltered.
+func renderFilteredPayload(service *v1.Service, endpoints *v1.Endpoints, fields []string) ([]byte, error) {
+	full, err := json.Marshal(newPayloadTemplateData(service, endpoints))
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return full, nil
+	}
+
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(full, &all); err != nil {
+		return nil, err
+	}
+	filtered := make(map[string]json.RawMessage, len(fields))
+	for _, field := range fields {
+		if v, ok := all[field]; ok {
+			filtered[field] = v
+		}
+	}
+	return json.Marshal(filtered)
+}
+
+// zkACLs converts a ZkAnnouncement's ACL into the go-zookeeper form
+// ensurePath/set expect, returning nil (the default World/PermAll ACL)
+// when acls is empty.
+func zkACLs(acls []ZkACL) []zk.ACL {
+	if len(acls) == 0 {
+		return nil
+	}
+	out := make([]zk.ACL, len(acls))
+	for i, acl := range acls {
+		out[i] = zk.ACL{Perms: acl.Perms, Scheme: acl.Scheme, ID: acl.ID}
+	}
+	return out
+}
+
+// labelSet adapts a plain label map to labels.Labels without requiring
+// callers to import k8s.io/apimachinery/pkg/labels themselves.
+type labelSet map[string]string
+
+func (ls labelSet) Has(key string) bool {
+	_, ok := ls[key]
+	return ok
+}
+
+func (ls labelSet) Get(key string) string {
+	return ls[key]
+}
+
+// updateAnnouncementStatus reports how many Services policy currently
+// matches via the status subresource, so operators can see the effect
+// of a ZkAnnouncement with kubectl get/describe.
+func updateAnnouncementStatus(client rest.Interface, policy *ZkAnnouncement, announcedServices int) error {
+	updated := policy.DeepCopyObject().(*ZkAnnouncement)
+	updated.Status.AnnouncedServices = int32(announcedServices)
+	updated.Status.LastSyncTime = metav1.Now()
+
+	return client.Put().
+		Namespace(updated.Namespace).
+		Resource("zkannouncements").
+		Name(updated.Name).
+		SubResource("status").
+		Body(updated).
+		Do().
+		Error()
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func policyWithSelector(name string, matchLabels map[string]string) *ZkAnnouncement {
+	return &ZkAnnouncement{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Spec: ZkAnnouncementSpec{
+			Selector: metav1.LabelSelector{MatchLabels: matchLabels},
+		},
+	}
+}
+
+func serviceWithLabels(name string, labels map[string]string) *v1.Service {
+	return &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, Labels: labels},
+	}
+}
+
+func TestMatchingPolicies(t *testing.T) {
+	policies := []*ZkAnnouncement{
+		policyWithSelector("frontend-policy", map[string]string{"tier": "frontend"}),
+		policyWithSelector("backend-policy", map[string]string{"tier": "backend"}),
+	}
+	service := serviceWithLabels("web", map[string]string{"tier": "frontend"})
+
+	matched := matchingPolicies(policies, service)
+	if len(matched) != 1 {
+		t.Fatalf("expected 1 matching policy, got %d", len(matched))
+	}
+	if matched[0].Name != "frontend-policy" {
+		t.Errorf("expected frontend-policy to match, got %s", matched[0].Name)
+	}
+}
+
+func TestCountAnnouncedServices(t *testing.T) {
+	policy := policyWithSelector("frontend-policy", map[string]string{"tier": "frontend"})
+	services := []*v1.Service{
+		serviceWithLabels("web-1", map[string]string{"tier": "frontend"}),
+		serviceWithLabels("web-2", map[string]string{"tier": "frontend"}),
+		serviceWithLabels("db", map[string]string{"tier": "backend"}),
+	}
+
+	count, err := countAnnouncedServices(services, policy)
+	if err != nil {
+		t.Fatalf("countAnnouncedServices() error: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("countAnnouncedServices() = %d, want 2", count)
+	}
+}
+
+func TestRenderPolicyPath(t *testing.T) {
+	policy := &ZkAnnouncement{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "frontend-policy"},
+		Spec:       ZkAnnouncementSpec{PathTemplate: "/discovery/{{.Namespace}}/{{.Name}}"},
+	}
+	service := serviceWithLabels("web", nil)
+
+	path, err := renderPolicyPath(policy, service)
+	if err != nil {
+		t.Fatalf("renderPolicyPath() error: %v", err)
+	}
+	if want := "/discovery/default/web"; path != want {
+		t.Errorf("renderPolicyPath() = %q, want %q", path, want)
+	}
+}
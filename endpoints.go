@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// podEndpoint describes a single backend address behind a Service,
+// the unit of information written to ZooKeeper as a child znode.
+type podEndpoint struct {
+	IP        string `json:"ip"`
+	Port      int32  `json:"port"`
+	NodeName  string `json:"nodeName,omitempty"`
+	Ready     bool   `json:"ready"`
+	TargetRef string `json:"targetRef,omitempty"`
+}
+
+// znodeChildPath returns the path of the per-pod child znode under a
+// Service's root path, e.g. /services/<ns>/<name>/<podIP>:<port>.
+func znodeChildPath(servicePath, ip string, port int32) string {
+	return fmt.Sprintf("%s/%s:%d", servicePath, ip, port)
+}
+
+// notReadyZnodeChildPath is the NotReadyAddresses counterpart, written
+// under a separate subtree so ready-only clients never see it.
+func notReadyZnodeChildPath(servicePath, ip string, port int32) string {
+	return fmt.Sprintf("%s/not-ready/%s:%d", servicePath, ip, port)
+}
+
+func podEndpointsFromSubset(subset v1.EndpointSubset, ready bool) []podEndpoint {
+	addresses := subset.Addresses
+	if !ready {
+		addresses = subset.NotReadyAddresses
+	}
+	endpoints := make([]podEndpoint, 0, len(addresses)*len(subset.Ports))
+	for _, addr := range addresses {
+		for _, port := range subset.Ports {
+			pe := podEndpoint{
+				IP:    addr.IP,
+				Port:  port.Port,
+				Ready: ready,
+			}
+			if addr.NodeName != nil {
+				pe.NodeName = *addr.NodeName
+			}
+			if addr.TargetRef != nil {
+				pe.TargetRef = string(addr.TargetRef.UID)
+			}
+			endpoints = append(endpoints, pe)
+		}
+	}
+	return endpoints
+}
+
+// podEndpointsFromEndpoints flattens every subset of an Endpoints object
+// into ready and not-ready podEndpoint entries.
+func podEndpointsFromEndpoints(endpoints *v1.Endpoints) []podEndpoint {
+	var result []podEndpoint
+	for _, subset := range endpoints.Subsets {
+		result = append(result, podEndpointsFromSubset(subset, true)...)
+		result = append(result, podEndpointsFromSubset(subset, false)...)
+	}
+	return result
+}
+
+func newEndpointsIndexerInformer(client kubernetes.Interface, namespace string, updateInterval time.Duration, handlers cache.ResourceEventHandlerFuncs) (cache.Indexer, cache.Controller) {
+	return cache.NewIndexerInformer(
+		&cache.ListWatch{
+			ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+				return client.Core().Endpoints(namespace).List(lo)
+			},
+			WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+				return client.Core().Endpoints(namespace).Watch(lo)
+			},
+		},
+		&v1.Endpoints{},
+		updateInterval,
+		handlers,
+		cache.Indexers{},
+	)
+}
+
+func endpointsHandlers(sc *serviceController) cache.ResourceEventHandlerFuncs {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			sc.onEndpointsUpdate(obj)
+		},
+		UpdateFunc: func(old, new interface{}) {
+			newEndpoints := new.(*v1.Endpoints)
+			oldEndpoints := old.(*v1.Endpoints)
+			if newEndpoints.ResourceVersion != oldEndpoints.ResourceVersion {
+				sc.onEndpointsUpdate(new)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			sc.onEndpointsDelete(obj)
+		},
+	}
+}
+
+// onEndpointsUpdate correlates the Endpoints object with its Service by
+// namespace/name and pushes an eventEndpointsUpdate so the updater can
+// reconcile per-pod znodes, pruning any that have disappeared.
+func (c *serviceController) onEndpointsUpdate(obj interface{}) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return
+	}
+	log.Debugf("endpointsUpdateFunc key: %v", key)
+
+	endpoints := obj.(*v1.Endpoints)
+	if _, err := c.serviceLister.Services(endpoints.Namespace).Get(endpoints.Name); err != nil {
+		log.Debugf("no matching service for endpoints %v, skipping: %v", key, err)
+		return
+	}
+
+	event, err := newUpdaterEndpointsEvent(eventEndpointsUpdate, endpoints)
+	if err != nil {
+		log.Debugf("failed to generate new updater endpoints event: %v", err.Error())
+		return
+	}
+	if c.clusterName != "" {
+		prefixed := make(map[string][]byte, len(event.children))
+		for childPath, data := range event.children {
+			prefixed["/"+c.clusterName+childPath] = data
+		}
+		event.path = "/" + c.clusterName + event.path
+		event.children = prefixed
+	}
+	c.updater.events <- *event
+}
+
+// onEndpointsDelete prunes the per-pod znodes for a Service whose
+// Endpoints object was removed (e.g. the Service itself was deleted).
+func (c *serviceController) onEndpointsDelete(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return
+	}
+	log.Debugf("endpointsDeleteFunc key: %v", key)
+
+	endpoints, ok := obj.(*v1.Endpoints)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			log.Debugf("couldn't get object from tombstone %+v", obj)
+			return
+		}
+		endpoints, ok = tombstone.Obj.(*v1.Endpoints)
+		if !ok {
+			log.Debugf("tombstone contained object that is not an Endpoints %+v", obj)
+			return
+		}
+	}
+
+	event, err := newUpdaterEndpointsEvent(eventEndpointsDelete, endpoints)
+	if err != nil {
+		log.Debugf("failed to generate new updater endpoints delete event: %v", err.Error())
+		return
+	}
+	if c.clusterName != "" {
+		event.path = "/" + c.clusterName + event.path
+	}
+	c.updater.events <- *event
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func strptr(s string) *string { return &s }
+
+func TestPodEndpointsFromSubset(t *testing.T) {
+	subset := v1.EndpointSubset{
+		Addresses: []v1.EndpointAddress{
+			{IP: "10.0.0.1", NodeName: strptr("node-a")},
+		},
+		NotReadyAddresses: []v1.EndpointAddress{
+			{IP: "10.0.0.2"},
+		},
+		Ports: []v1.EndpointPort{
+			{Port: 8080},
+		},
+	}
+
+	ready := podEndpointsFromSubset(subset, true)
+	if len(ready) != 1 {
+		t.Fatalf("expected 1 ready endpoint, got %d", len(ready))
+	}
+	if ready[0].IP != "10.0.0.1" || ready[0].Port != 8080 || !ready[0].Ready {
+		t.Errorf("unexpected ready endpoint: %+v", ready[0])
+	}
+	if ready[0].NodeName != "node-a" {
+		t.Errorf("expected nodeName node-a, got %q", ready[0].NodeName)
+	}
+
+	notReady := podEndpointsFromSubset(subset, false)
+	if len(notReady) != 1 {
+		t.Fatalf("expected 1 not-ready endpoint, got %d", len(notReady))
+	}
+	if notReady[0].IP != "10.0.0.2" || notReady[0].Ready {
+		t.Errorf("unexpected not-ready endpoint: %+v", notReady[0])
+	}
+}
+
+func TestPodEndpointsFromEndpoints(t *testing.T) {
+	endpoints := &v1.Endpoints{
+		Subsets: []v1.EndpointSubset{
+			{
+				Addresses:         []v1.EndpointAddress{{IP: "10.0.0.1"}},
+				NotReadyAddresses: []v1.EndpointAddress{{IP: "10.0.0.2"}},
+				Ports:             []v1.EndpointPort{{Port: 80}},
+			},
+		},
+	}
+
+	result := podEndpointsFromEndpoints(endpoints)
+	if len(result) != 2 {
+		t.Fatalf("expected 2 endpoints (ready + not-ready), got %d", len(result))
+	}
+
+	var sawReady, sawNotReady bool
+	for _, pe := range result {
+		if pe.IP == "10.0.0.1" && pe.Ready {
+			sawReady = true
+		}
+		if pe.IP == "10.0.0.2" && !pe.Ready {
+			sawNotReady = true
+		}
+	}
+	if !sawReady || !sawNotReady {
+		t.Errorf("expected both a ready and not-ready entry, got %+v", result)
+	}
+}
+
+func TestZnodeChildPaths(t *testing.T) {
+	if got, want := znodeChildPath("/services/ns/svc", "10.0.0.1", 80), "/services/ns/svc/10.0.0.1:80"; got != want {
+		t.Errorf("znodeChildPath() = %q, want %q", got, want)
+	}
+	if got, want := notReadyZnodeChildPath("/services/ns/svc", "10.0.0.1", 80), "/services/ns/svc/not-ready/10.0.0.1:80"; got != want {
+		t.Errorf("notReadyZnodeChildPath() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	log "github.com/sirupsen/logrus"
+	"k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const eventSourceComponent = "zk-announser"
+
+// Event reasons recorded against the Service a znode is announced for,
+// surfaced through `kubectl describe service` for operators without log
+// access.
+const (
+	EventReasonAnnounced      = "Announced"
+	EventReasonAnnounceFailed = "AnnounceFailed"
+	EventReasonWithdrawn      = "Withdrawn"
+	EventReasonWithdrawFailed = "WithdrawFailed"
+)
+
+// newEventRecorder builds a record.EventRecorder that posts Events
+// against objects in namespace, identifying itself as eventSourceComponent.
+func newEventRecorder(client kubernetes.Interface, namespace string) record.EventRecorder {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartLogging(log.Debugf)
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{
+		Interface: client.Core().Events(namespace),
+	})
+
+	return broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: eventSourceComponent})
+}
@@ -1,11 +1,13 @@
 package main
 
 import (
+	"text/template"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 	"k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
@@ -13,6 +15,7 @@ import (
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/record"
 )
 
 func k8sGetClientConfig(kubeconfig string) (*rest.Config, error) {
@@ -37,28 +40,58 @@ func k8sGetClient(kubeconfig string) (*kubernetes.Clientset, error) {
 }
 
 type serviceController struct {
-	client        kubernetes.Interface
-	informer      cache.Controller
-	indexer       cache.Indexer
-	serviceLister lister_v1.ServiceLister
-	updater       *Updater
+	client            kubernetes.Interface
+	informer          cache.Controller
+	indexer           cache.Indexer
+	serviceLister     lister_v1.ServiceLister
+	endpointsInformer cache.Controller
+	endpointsIndexer  cache.Indexer
+	policyClient      rest.Interface
+	policyInformer    cache.Controller
+	policyIndexer     cache.Indexer
+	policyLister      ZkAnnouncementLister
+	recorder          record.EventRecorder
+	clusterName       string
+	updater           *Updater
+	ownsUpdater       bool
+	watchHealth       *watchHealth
 }
 
-func newServiceController(client kubernetes.Interface, namespace string, updateInterval time.Duration, zookeeperAddr string) *serviceController {
+// newServiceController wires up the Service and Endpoints informers and,
+// when policyClient is non-nil, a ZkAnnouncement informer whose policies
+// decide which Services get announced and under what path. policyClient
+// may be nil to preserve the legacy "announce every Service" behavior.
+// payloadTemplate/format may also be nil/empty to keep the updater's
+// built-in payload construction. clusterName prefixes every znode path
+// this controller writes (e.g. "/<clusterName>/services/<ns>/<name>"),
+// letting a single ZooKeeper ensemble serve several (cluster, namespace)
+// watches without collisions; pass "" when multi-cluster support isn't
+// needed. sharedUpdater lets several serviceControllers multiplex their
+// events into one Updater/ZK session instead of each opening its own;
+// pass nil to have this controller create and own its Updater as before.
+func newServiceController(client kubernetes.Interface, policyClient rest.Interface, namespace string, updateInterval time.Duration, zookeeperAddr string, payloadTemplate *template.Template, format payloadFormat, clusterName string, sharedUpdater *Updater) *serviceController {
 	sc := &serviceController{
-		client: client,
+		client:       client,
+		policyClient: policyClient,
+		recorder:     newEventRecorder(client, namespace),
+		clusterName:  clusterName,
+		watchHealth:  &watchHealth{},
+	}
+	if sharedUpdater != nil {
+		sc.updater = sharedUpdater
+	} else {
+		sc.updater = newUpdater(zookeeperAddr, sc.recorder, payloadTemplate, format)
+		sc.ownsUpdater = true
 	}
-	sc.updater = newUpdater(zookeeperAddr)
 
 	indexer, informer := cache.NewIndexerInformer(
 		&cache.ListWatch{
 			ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
 				return client.Core().Services(namespace).List(lo)
 			},
-			WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+			WatchFunc: sc.watchHealth.wrapWatchFunc(func(lo metav1.ListOptions) (watch.Interface, error) {
 				return client.Core().Services(namespace).Watch(lo)
-
-			},
+			}),
 		},
 		// The types of objects this informer will return
 		&v1.Service{},
@@ -69,13 +102,7 @@ func newServiceController(client kubernetes.Interface, namespace string, updateI
 				if key, err := cache.MetaNamespaceKeyFunc(obj); err == nil {
 					log.Debugf("addFunc key: %v", key)
 					service := obj.(*v1.Service)
-					event, err := newUpdaterEvent(eventCreate, service)
-					if err != nil {
-						log.Debugf("failed to generate new updater event: %v", err.Error())
-					} else {
-						sc.updater.events <- *event
-					}
-
+					sc.announceService(eventCreate, service)
 				}
 			},
 			UpdateFunc: func(old, new interface{}) {
@@ -85,12 +112,7 @@ func newServiceController(client kubernetes.Interface, namespace string, updateI
 					oldService := old.(*v1.Service)
 
 					if newService.ResourceVersion != oldService.ResourceVersion {
-						event, err := newUpdaterEvent(eventUpdate, newService)
-						if err != nil {
-							log.Debugf("failed to generate new updater event: %v", err.Error())
-						} else {
-							sc.updater.events <- *event
-						}
+						sc.announceService(eventUpdate, newService)
 					}
 				}
 			},
@@ -98,12 +120,7 @@ func newServiceController(client kubernetes.Interface, namespace string, updateI
 				if key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj); err == nil {
 					log.Debugf("deleteFunc key: %v", key)
 					service := obj.(*v1.Service)
-					event, err := newUpdaterEvent(eventDelete, service)
-					if err != nil {
-						log.Debugf("failed to generate new updater event: %v", err.Error())
-					} else {
-						sc.updater.events <- *event
-					}
+					sc.announceService(eventDelete, service)
 				}
 			},
 		},
@@ -114,14 +131,97 @@ func newServiceController(client kubernetes.Interface, namespace string, updateI
 	sc.indexer = indexer
 	sc.serviceLister = lister_v1.NewServiceLister(indexer)
 
+	endpointsIndexer, endpointsInformer := newEndpointsIndexerInformer(client, namespace, updateInterval, endpointsHandlers(sc))
+	sc.endpointsIndexer = endpointsIndexer
+	sc.endpointsInformer = endpointsInformer
+
+	if policyClient != nil {
+		policyIndexer, policyInformer := newZkAnnouncementIndexerInformer(policyClient, namespace, updateInterval, cache.ResourceEventHandlerFuncs{})
+		sc.policyIndexer = policyIndexer
+		sc.policyInformer = policyInformer
+		sc.policyLister = NewZkAnnouncementLister(policyIndexer)
+	}
+
 	return sc
 }
 
+// announceService pushes an updater event for service, filtered and
+// transformed through any matching ZkAnnouncement policies. When no
+// policy client is configured it falls back to the legacy behavior of
+// announcing every Service under its default path.
+func (c *serviceController) announceService(evt eventType, service *v1.Service) {
+	if c.policyLister == nil {
+		var event *updaterEvent
+		var err error
+		if c.clusterName != "" {
+			event, err = c.updater.newUpdaterEventWithPath(evt, service, clusterServicePath(c.clusterName, service))
+		} else {
+			event, err = c.updater.newUpdaterEvent(evt, service)
+		}
+		if err != nil {
+			log.Debugf("failed to generate new updater event: %v", err.Error())
+			return
+		}
+		c.updater.events <- *event
+		return
+	}
+
+	policies, err := c.policyLister.ZkAnnouncements(service.Namespace).List(labels.Everything())
+	if err != nil {
+		log.Debugf("failed to list ZkAnnouncement policies in %s: %v", service.Namespace, err)
+		return
+	}
+
+	matched := matchingPolicies(policies, service)
+	if len(matched) == 0 {
+		log.Debugf("service %s/%s matches no ZkAnnouncement policy, skipping", service.Namespace, service.Name)
+		return
+	}
+
+	services, err := c.serviceLister.Services(service.Namespace).List(labels.Everything())
+	if err != nil {
+		log.Debugf("failed to list services in %s for status reporting: %v", service.Namespace, err)
+	}
+
+	for _, policy := range matched {
+		path, err := renderPolicyPath(policy, service)
+		if err != nil {
+			log.Debugf("policy %s/%s path template failed: %v", policy.Namespace, policy.Name, err)
+			continue
+		}
+		if c.clusterName != "" {
+			path = "/" + c.clusterName + path
+		}
+
+		event, err := c.updater.newPolicyUpdaterEvent(evt, service, path, policy)
+		if err != nil {
+			log.Debugf("failed to generate new updater event: %v", err.Error())
+			continue
+		}
+		c.updater.events <- *event
+
+		count, err := countAnnouncedServices(services, policy)
+		if err != nil {
+			log.Debugf("policy %s/%s has an invalid selector: %v", policy.Namespace, policy.Name, err)
+			continue
+		}
+		if err := updateAnnouncementStatus(c.policyClient, policy, count); err != nil {
+			log.Debugf("failed to update status of policy %s/%s: %v", policy.Namespace, policy.Name, err)
+		}
+	}
+}
+
 func (c *serviceController) Run(stopCh chan struct{}) {
 	log.Info("Starting serviceController")
 
 	go c.informer.Run(stopCh)
-	go c.updater.Run(stopCh)
+	go c.endpointsInformer.Run(stopCh)
+	if c.policyInformer != nil {
+		go c.policyInformer.Run(stopCh)
+	}
+	if c.ownsUpdater {
+		go c.updater.Run(stopCh)
+	}
 
 	<-stopCh
 	log.Info("Stopping serviceController")
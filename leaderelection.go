@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const (
+	defaultLeaseNamespace = "kube-system"
+	defaultLeaseName      = "zk-announser"
+)
+
+// leaderElectionFlags holds the --leader-election-* flag values used to
+// configure leaderElector. It is kept separate from serviceController so
+// HA operation can be disabled entirely by callers that only run a
+// single replica.
+type leaderElectionFlags struct {
+	Enabled        bool
+	LeaseNamespace string
+	LeaseName      string
+	LeaseDuration  time.Duration
+	RenewDeadline  time.Duration
+	RetryPeriod    time.Duration
+	HolderIdentity string
+}
+
+// holderIdentity defaults to the pod name injected via the downward API,
+// falling back to the hostname when running outside Kubernetes.
+func holderIdentity() string {
+	if pod := os.Getenv("POD_NAME"); pod != "" {
+		return pod
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "zk-announser"
+	}
+	return hostname
+}
+
+// newLeaderElector builds a LeaderElector backed by a coordination.k8s.io
+// Lease, calling onStartedLeading when this replica acquires the lease
+// and onStoppedLeading when it loses it.
+func newLeaderElector(client kubernetes.Interface, flags leaderElectionFlags, onStartedLeading func(context.Context), onStoppedLeading func()) (*leaderelection.LeaderElector, error) {
+	identity := flags.HolderIdentity
+	if identity == "" {
+		identity = holderIdentity()
+	}
+
+	leaseNamespace := flags.LeaseNamespace
+	if leaseNamespace == "" {
+		leaseNamespace = defaultLeaseNamespace
+	}
+	leaseName := flags.LeaseName
+	if leaseName == "" {
+		leaseName = defaultLeaseName
+	}
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Namespace: leaseNamespace,
+			Name:      leaseName,
+		},
+		Client: client.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: identity,
+		},
+	}
+
+	return leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		LeaseDuration: flags.LeaseDuration,
+		RenewDeadline: flags.RenewDeadline,
+		RetryPeriod:   flags.RetryPeriod,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: onStartedLeading,
+			OnStoppedLeading: onStoppedLeading,
+		},
+	})
+}
+
+// runWithLeaderElection runs serviceController only while this replica
+// holds the zk-announser Lease, so --replicas=2+ can run safely without
+// double-writing to ZooKeeper. OnStoppedLeading closes that cycle's
+// stopCh so the controller's ZK session is torn down cleanly before
+// another replica takes over.
+//
+// Per client-go's documented LeaderElector usage, a single elector.Run
+// call only covers one acquire/renew/lose cycle: losing the lease (a
+// transient renew failure, not just a deliberate step-down) makes Run
+// return, and the caller is expected to call it again to keep
+// contending. runWithLeaderElection loops elector.Run until the process
+// receives SIGTERM/SIGINT, instead of falling out of main() after the
+// first lost lease and relying on a pod restart to rejoin the election.
+func runWithLeaderElection(client kubernetes.Interface, flags leaderElectionFlags, sc *serviceController) error {
+	var stopCh chan struct{}
+
+	elector, err := newLeaderElector(client, flags,
+		func(ctx context.Context) {
+			log.Info("acquired leader election lease, starting controller")
+			stopCh = make(chan struct{})
+			sc.Run(stopCh)
+		},
+		func() {
+			if stopCh == nil {
+				return
+			}
+			log.Info("lost leader election lease, stopping controller")
+			close(stopCh)
+			stopCh = nil
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	shutdown := make(chan os.Signal, 1)
+	signal.Notify(shutdown, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		<-shutdown
+		log.Info("received shutdown signal, stopping leader election")
+		cancel()
+	}()
+
+	for ctx.Err() == nil {
+		elector.Run(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"text/template"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func main() {
+	var (
+		kubeconfig       = flag.String("kubeconfig", "", "path to a kubeconfig; uses in-cluster config when empty")
+		namespace        = flag.String("namespace", "", "namespace to watch; empty watches all namespaces")
+		zookeeperAddr    = flag.String("zookeeper", "localhost:2181", "comma-separated ZooKeeper host:port ensemble")
+		updateInterval   = flag.Duration("update-interval", 30*time.Second, "informer resync interval")
+		clusterName      = flag.String("cluster-name", "", "prefixes every znode path with this cluster name")
+		clusterTargets   = flag.String("clusters", "", "comma-separated context=namespace-selector pairs for multi-cluster mode, with contexts read from -kubeconfig; replaces -namespace/-cluster-name, and -payload-template, -payload-format, -zk-announcement-crd and -leader-election are not applied")
+		payloadTemplate  = flag.String("payload-template", "", "path to a Go text/template rendering the znode payload; empty uses the built-in JSON rendering")
+		payloadFormatStr = flag.String("payload-format", string(payloadFormatJSON), "how to treat --payload-template output: json, yaml or raw")
+		enableCRD        = flag.Bool("zk-announcement-crd", false, "watch ZkAnnouncement policies instead of announcing every Service")
+		debug            = flag.Bool("debug", false, "enable debug logging")
+
+		leaderElection = flag.Bool("leader-election", false, "run only while holding a coordination.k8s.io Lease, for HA replicas")
+		leaseNamespace = flag.String("leader-election-namespace", defaultLeaseNamespace, "namespace of the Lease used for leader election")
+		leaseName      = flag.String("leader-election-lease-name", defaultLeaseName, "name of the Lease used for leader election")
+		leaseDuration  = flag.Duration("leader-election-lease-duration", 15*time.Second, "leader election lease duration")
+		renewDeadline  = flag.Duration("leader-election-renew-deadline", 10*time.Second, "leader election renew deadline")
+		retryPeriod    = flag.Duration("leader-election-retry-period", 2*time.Second, "leader election retry period")
+		holderIdentity = flag.String("leader-election-identity", "", "holder identity for leader election; defaults to the pod name (POD_NAME) or hostname")
+	)
+	flag.Parse()
+
+	if *debug {
+		log.SetLevel(log.DebugLevel)
+	}
+
+	format := payloadFormat(*payloadFormatStr)
+	tmpl, err := loadPayloadTemplateFlag(*payloadTemplate)
+	if err != nil {
+		log.Fatalf("loading --payload-template: %v", err)
+	}
+
+	if *clusterTargets != "" {
+		runMultiCluster(*kubeconfig, *clusterTargets, *updateInterval, *zookeeperAddr)
+		return
+	}
+
+	config, err := k8sGetClientConfig(*kubeconfig)
+	if err != nil {
+		log.Fatalf("building kubernetes client config: %v", err)
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		log.Fatalf("building kubernetes client: %v", err)
+	}
+
+	policyClient, err := policyClientOrNil(config, *enableCRD)
+	if err != nil {
+		log.Fatalf("building ZkAnnouncement client: %v", err)
+	}
+
+	sc := newServiceController(client, policyClient, *namespace, *updateInterval, *zookeeperAddr, tmpl, format, *clusterName, nil)
+
+	if !*leaderElection {
+		stopCh := make(chan struct{})
+		sc.Run(stopCh)
+		return
+	}
+
+	flags := leaderElectionFlags{
+		Enabled:        true,
+		LeaseNamespace: *leaseNamespace,
+		LeaseName:      *leaseName,
+		LeaseDuration:  *leaseDuration,
+		RenewDeadline:  *renewDeadline,
+		RetryPeriod:    *retryPeriod,
+		HolderIdentity: *holderIdentity,
+	}
+	if err := runWithLeaderElection(client, flags, sc); err != nil {
+		log.Fatalf("leader election: %v", err)
+	}
+}
+
+// loadPayloadTemplateFlag wraps loadPayloadTemplate, treating an empty
+// path as "no template configured" rather than an error.
+func loadPayloadTemplateFlag(path string) (*template.Template, error) {
+	if path == "" {
+		return nil, nil
+	}
+	return loadPayloadTemplate(path)
+}
+
+// policyClientOrNil builds a ZkAnnouncement REST client when enableCRD
+// is set, so newServiceController falls back to the legacy
+// "announce every Service" behavior otherwise.
+func policyClientOrNil(config *rest.Config, enableCRD bool) (rest.Interface, error) {
+	if !enableCRD {
+		return nil, nil
+	}
+	return newZkAnnouncementClient(config)
+}
+
+// runMultiCluster parses --clusters into clusterWatchTargets and runs a
+// clusterControllerSet for them.
+func runMultiCluster(kubeconfig, clusterTargets string, updateInterval time.Duration, zookeeperAddr string) {
+	var targets []clusterWatchTarget
+	for _, pair := range strings.Split(clusterTargets, ",") {
+		context, selector := pair, ""
+		if idx := strings.Index(pair, "="); idx >= 0 {
+			context, selector = pair[:idx], pair[idx+1:]
+		}
+		targets = append(targets, clusterWatchTarget{Context: context, NamespaceSelector: selector})
+	}
+
+	set, err := newClusterControllerSet(kubeconfig, targets, updateInterval, zookeeperAddr)
+	if err != nil {
+		log.Fatalf("building cluster controller set: %v", err)
+	}
+
+	stopCh := make(chan struct{})
+	set.Run(stopCh)
+}
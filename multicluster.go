@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// clusterWatchTarget is one (kubeconfig-context, namespace-selector)
+// pair to watch. Context selects a context out of the shared kubeconfig
+// (empty uses the current context, or in-cluster config when kubeconfig
+// is also empty); NamespaceSelector is one of:
+//   - "" or metav1.NamespaceAll: every namespace in the cluster
+//   - a comma-separated list of namespace names
+//   - a label selector, matched against Namespace objects
+type clusterWatchTarget struct {
+	Context           string
+	NamespaceSelector string
+}
+
+// clusterServicePath prefixes a Service's default znode path with its
+// cluster name, so one ZooKeeper ensemble can serve several clusters'
+// announcements without the paths colliding.
+func clusterServicePath(clusterName string, service *v1.Service) string {
+	return fmt.Sprintf("/%s/services/%s/%s", clusterName, service.Namespace, service.Name)
+}
+
+// clusterControllerSet is one serviceController per (cluster, namespace)
+// pair, multiplexed into a single Updater/ZK session.
+type clusterControllerSet struct {
+	controllers []*serviceController
+	updater     *Updater
+}
+
+// newClusterControllerSet resolves each target's namespace selector and
+// builds one serviceController per (cluster, namespace) pair, all of
+// which push into a single shared Updater so one ZooKeeper session
+// serves every watched cluster.
+func newClusterControllerSet(kubeconfig string, targets []clusterWatchTarget, updateInterval time.Duration, zookeeperAddr string) (*clusterControllerSet, error) {
+	set := &clusterControllerSet{}
+
+	for _, target := range targets {
+		config, err := k8sGetClientConfigForContext(kubeconfig, target.Context)
+		if err != nil {
+			return nil, fmt.Errorf("building client config for context %q: %v", target.Context, err)
+		}
+		client, err := kubernetes.NewForConfig(config)
+		if err != nil {
+			return nil, fmt.Errorf("building client for context %q: %v", target.Context, err)
+		}
+
+		namespaces, err := resolveNamespaceSelector(client, target.NamespaceSelector)
+		if err != nil {
+			return nil, fmt.Errorf("resolving namespace selector %q for context %q: %v", target.NamespaceSelector, target.Context, err)
+		}
+
+		clusterName := target.Context
+		if clusterName == "" {
+			clusterName = "default"
+		}
+
+		for _, namespace := range namespaces {
+			sc := newServiceController(client, nil, namespace, updateInterval, zookeeperAddr, nil, payloadFormatJSON, clusterName, set.updater)
+			if set.updater == nil {
+				set.updater = sc.updater
+			}
+			set.controllers = append(set.controllers, sc)
+		}
+	}
+
+	return set, nil
+}
+
+// k8sGetClientConfigForContext is like k8sGetClientConfig but allows
+// selecting a non-current context out of kubeconfig, for watching
+// multiple clusters from one process.
+func k8sGetClientConfigForContext(kubeconfig, context string) (*rest.Config, error) {
+	if kubeconfig == "" && context == "" {
+		return rest.InClusterConfig()
+	}
+
+	overrides := &clientcmd.ConfigOverrides{}
+	if context != "" {
+		overrides.CurrentContext = context
+	}
+
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfig},
+		overrides,
+	).ClientConfig()
+}
+
+// namespaceNameRE matches a valid Kubernetes namespace name (a DNS-1123
+// label). A bare word like "default" or "kube-system" is also valid
+// label selector syntax (an existence check on that key), so it can't
+// be told apart from a namespace name by labels.Parse succeeding; we
+// only treat a selector as a real label selector once it uses syntax
+// (=, !=, in/notin, whitespace) that no namespace name can contain.
+var namespaceNameRE = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// resolveNamespaceSelector expands a NamespaceSelector into a concrete
+// list of namespace names to watch.
+func resolveNamespaceSelector(client kubernetes.Interface, selector string) ([]string, error) {
+	switch {
+	case selector == "" || selector == metav1.NamespaceAll:
+		return []string{metav1.NamespaceAll}, nil
+	case strings.Contains(selector, ","):
+		return strings.Split(selector, ","), nil
+	case namespaceNameRE.MatchString(selector):
+		return []string{selector}, nil
+	default:
+		if _, err := labels.Parse(selector); err != nil {
+			return nil, fmt.Errorf("invalid namespace selector %q: %v", selector, err)
+		}
+		namespaces, err := client.Core().Namespaces().List(metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, 0, len(namespaces.Items))
+		for _, ns := range namespaces.Items {
+			names = append(names, ns.Name)
+		}
+		return names, nil
+	}
+}
+
+const (
+	clusterHealthCheckInterval = 30 * time.Second
+	clusterUnhealthyThreshold  = 5 * time.Minute
+)
+
+// Run starts every controller in the set and the single shared Updater
+// against the global stopCh, so an ordinary shutdown tears every
+// informer down without touching ZooKeeper. Each cluster is watched
+// independently for its own watch going dark (see watchClusterHealth),
+// which is the only thing that withdraws its services.
+func (s *clusterControllerSet) Run(stopCh chan struct{}) {
+	log.Info("Starting clusterControllerSet")
+
+	go s.updater.Run(stopCh)
+
+	for _, sc := range s.controllers {
+		sc := sc
+		go sc.informer.Run(stopCh)
+		go sc.endpointsInformer.Run(stopCh)
+		go s.watchClusterHealth(sc, stopCh)
+	}
+
+	<-stopCh
+	log.Info("Stopping clusterControllerSet")
+}
+
+// watchClusterHealth polls sc's watchHealth independently of the global
+// stop signal, withdrawing sc's cached Services only once its watch has
+// failed to reconnect for clusterUnhealthyThreshold -- i.e. the
+// cluster's watch has genuinely dropped for good, not merely because
+// the whole process is shutting down. A routine restart closes stopCh
+// and this goroutine simply returns without withdrawing anything.
+//
+// This deliberately does not use sc.informer.HasSynced(): HasSynced()
+// only reports whether the informer's initial list completed, and
+// stays true forever afterward even while the reflector silently
+// retries a dead connection in the background, so it can never catch
+// the one thing this check exists for.
+func (s *clusterControllerSet) watchClusterHealth(sc *serviceController, stopCh chan struct{}) {
+	ticker := time.NewTicker(clusterHealthCheckInterval)
+	defer ticker.Stop()
+
+	var unhealthySince time.Time
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			if sc.watchHealth.Err() == nil {
+				unhealthySince = time.Time{}
+				continue
+			}
+			if unhealthySince.IsZero() {
+				unhealthySince = time.Now()
+				continue
+			}
+			if time.Since(unhealthySince) >= clusterUnhealthyThreshold {
+				s.withdrawCluster(sc)
+				return
+			}
+		}
+	}
+}
+
+// withdrawCluster prunes every znode for sc's currently cached Services,
+// as if each had been deleted, when sc's watch stops.
+func (s *clusterControllerSet) withdrawCluster(sc *serviceController) {
+	log.Infof("cluster %s watch stopped, withdrawing its services", sc.clusterName)
+	for _, obj := range sc.endpointsIndexer.List() {
+		sc.onEndpointsDelete(obj)
+	}
+	for _, obj := range sc.indexer.List() {
+		service, ok := obj.(*v1.Service)
+		if !ok {
+			continue
+		}
+		sc.announceService(eventDelete, service)
+	}
+}
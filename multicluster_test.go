@@ -0,0 +1,74 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestResolveNamespaceSelectorPlainName(t *testing.T) {
+	for _, name := range []string{"default", "kube-system", "my-app"} {
+		got, err := resolveNamespaceSelector(fake.NewSimpleClientset(), name)
+		if err != nil {
+			t.Fatalf("resolveNamespaceSelector(%q) error: %v", name, err)
+		}
+		if want := []string{name}; !reflect.DeepEqual(got, want) {
+			t.Errorf("resolveNamespaceSelector(%q) = %v, want %v (should watch the namespace directly, not run it through a label List)", name, got, want)
+		}
+	}
+}
+
+func TestResolveNamespaceSelectorAll(t *testing.T) {
+	for _, selector := range []string{"", metav1.NamespaceAll} {
+		got, err := resolveNamespaceSelector(fake.NewSimpleClientset(), selector)
+		if err != nil {
+			t.Fatalf("resolveNamespaceSelector(%q) error: %v", selector, err)
+		}
+		if want := []string{metav1.NamespaceAll}; !reflect.DeepEqual(got, want) {
+			t.Errorf("resolveNamespaceSelector(%q) = %v, want %v", selector, got, want)
+		}
+	}
+}
+
+func TestResolveNamespaceSelectorCommaList(t *testing.T) {
+	got, err := resolveNamespaceSelector(fake.NewSimpleClientset(), "foo,bar")
+	if err != nil {
+		t.Fatalf("resolveNamespaceSelector() error: %v", err)
+	}
+	if want := []string{"foo", "bar"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("resolveNamespaceSelector() = %v, want %v", got, want)
+	}
+}
+
+func TestResolveNamespaceSelectorLabelSelector(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a", Labels: map[string]string{"team": "a"}}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b", Labels: map[string]string{"team": "b"}}},
+	)
+
+	got, err := resolveNamespaceSelector(client, "team=a")
+	if err != nil {
+		t.Fatalf("resolveNamespaceSelector() error: %v", err)
+	}
+	sort.Strings(got)
+	if want := []string{"team-a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("resolveNamespaceSelector() = %v, want %v", got, want)
+	}
+}
+
+func TestResolveNamespaceSelectorInvalid(t *testing.T) {
+	if _, err := resolveNamespaceSelector(fake.NewSimpleClientset(), "team ="); err == nil {
+		t.Error("expected an error for a malformed label selector, got nil")
+	}
+}
+
+func TestClusterServicePath(t *testing.T) {
+	service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}}
+	if got, want := clusterServicePath("prod-us", service), "/prod-us/services/default/web"; got != want {
+		t.Errorf("clusterServicePath() = %q, want %q", got, want)
+	}
+}
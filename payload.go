@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"text/template"
+
+	"k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// payloadFormat selects how a rendered template's output is treated
+// before it is written to a znode.
+type payloadFormat string
+
+const (
+	payloadFormatJSON payloadFormat = "json"
+	payloadFormatYAML payloadFormat = "yaml"
+	payloadFormatRaw  payloadFormat = "raw"
+)
+
+// payloadTemplateData is the context exposed to --payload-template. It
+// is also marshaled to JSON and filtered by a ZkAnnouncement's
+// PayloadFields (see renderFilteredPayload in announcement.go), so the
+// json tags here double as the field names policies select by.
+type payloadTemplateData struct {
+	Service     *v1.Service       `json:"service"`
+	Endpoints   *v1.Endpoints     `json:"endpoints"`
+	Namespace   string            `json:"namespace"`
+	Name        string            `json:"name"`
+	ClusterIP   string            `json:"clusterIP"`
+	Ports       []v1.ServicePort  `json:"ports"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+func newPayloadTemplateData(service *v1.Service, endpoints *v1.Endpoints) payloadTemplateData {
+	return payloadTemplateData{
+		Service:     service,
+		Endpoints:   endpoints,
+		Namespace:   service.Namespace,
+		Name:        service.Name,
+		ClusterIP:   service.Spec.ClusterIP,
+		Ports:       service.Spec.Ports,
+		Annotations: service.Annotations,
+	}
+}
+
+// loadPayloadTemplate parses and validates path as a Go text/template at
+// startup, so a malformed --payload-template fails fast rather than on
+// the first announce.
+func loadPayloadTemplate(path string) (*template.Template, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading payload template %s: %v", path, err)
+	}
+
+	tmpl, err := template.New("payload").Parse(string(contents))
+	if err != nil {
+		return nil, fmt.Errorf("parsing payload template %s: %v", path, err)
+	}
+	return tmpl, nil
+}
+
+// renderPayload executes tmpl against service/endpoints and coerces the
+// result to the requested format. For payloadFormatJSON/YAML the
+// template output is decoded and re-marshaled so malformed structure is
+// caught here rather than written to ZooKeeper.
+func renderPayload(tmpl *template.Template, format payloadFormat, service *v1.Service, endpoints *v1.Endpoints) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, newPayloadTemplateData(service, endpoints)); err != nil {
+		return nil, fmt.Errorf("executing payload template: %v", err)
+	}
+
+	switch format {
+	case payloadFormatJSON:
+		var v interface{}
+		if err := json.Unmarshal(buf.Bytes(), &v); err != nil {
+			return nil, fmt.Errorf("payload template did not render valid JSON: %v", err)
+		}
+		return json.Marshal(v)
+	case payloadFormatYAML:
+		var v interface{}
+		if err := yaml.Unmarshal(buf.Bytes(), &v); err != nil {
+			return nil, fmt.Errorf("payload template did not render valid YAML: %v", err)
+		}
+		return yaml.Marshal(v)
+	case payloadFormatRaw:
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unknown payload format %q", format)
+	}
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/samuel/go-zookeeper/zk"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// eventType identifies what a controller observed and what the updater
+// should therefore do to ZooKeeper.
+type eventType int
+
+const (
+	eventCreate eventType = iota
+	eventUpdate
+	eventDelete
+	eventEndpointsUpdate
+	eventEndpointsDelete
+)
+
+// updaterEvent is a single unit of work pushed onto an Updater's events
+// channel by a controller. eventCreate/eventUpdate/eventDelete carry
+// path/data describing a single znode write or delete for a Service.
+// eventEndpointsUpdate/eventEndpointsDelete carry children describing
+// the full desired set of per-pod child znodes under path, so the
+// updater can reconcile (and prune) them in one pass.
+type updaterEvent struct {
+	evt      eventType
+	path     string
+	data     []byte
+	service  *v1.Service
+	children map[string][]byte
+	acl      []zk.ACL
+}
+
+// Updater owns the ZooKeeper session and serializes every znode write
+// through a single goroutine (Run), so the controllers that produce
+// events never touch the ZK connection directly.
+type Updater struct {
+	zookeeperAddr   string
+	conn            *zk.Conn
+	events          chan updaterEvent
+	recorder        record.EventRecorder
+	payloadTemplate *template.Template
+	format          payloadFormat
+}
+
+// newUpdater builds an Updater that writes to the ZooKeeper ensemble at
+// zookeeperAddr (a comma-separated host:port list). recorder may be nil
+// to disable Kubernetes Event recording. tmpl may be nil to fall back to
+// the default JSON rendering of a Service's namespace/name/clusterIP/ports.
+func newUpdater(zookeeperAddr string, recorder record.EventRecorder, tmpl *template.Template, format payloadFormat) *Updater {
+	return &Updater{
+		zookeeperAddr:   zookeeperAddr,
+		events:          make(chan updaterEvent, 64),
+		recorder:        recorder,
+		payloadTemplate: tmpl,
+		format:          format,
+	}
+}
+
+// renderServicePayload renders a Service's znode payload: through the
+// configured --payload-template when one was loaded, falling back to a
+// built-in JSON rendering of namespace/name/clusterIP/ports otherwise.
+func (u *Updater) renderServicePayload(service *v1.Service) ([]byte, error) {
+	if u.payloadTemplate != nil {
+		return renderPayload(u.payloadTemplate, u.format, service, nil)
+	}
+
+	data := struct {
+		Namespace string           `json:"namespace"`
+		Name      string           `json:"name"`
+		ClusterIP string           `json:"clusterIP"`
+		Ports     []v1.ServicePort `json:"ports"`
+	}{
+		Namespace: service.Namespace,
+		Name:      service.Name,
+		ClusterIP: service.Spec.ClusterIP,
+		Ports:     service.Spec.Ports,
+	}
+	return json.Marshal(data)
+}
+
+// defaultServicePath is the legacy "announce every Service" znode path,
+// used when no ZkAnnouncement policy or cluster prefix applies.
+func defaultServicePath(service *v1.Service) string {
+	return fmt.Sprintf("/services/%s/%s", service.Namespace, service.Name)
+}
+
+// newUpdaterEvent builds the event for a Service under its default path.
+func (u *Updater) newUpdaterEvent(evt eventType, service *v1.Service) (*updaterEvent, error) {
+	return u.newUpdaterEventWithPath(evt, service, defaultServicePath(service))
+}
+
+// newUpdaterEventWithPath is like newUpdaterEvent but writes to
+// znodePath instead of the default path, for cluster-prefixed or
+// policy-driven announcements.
+func (u *Updater) newUpdaterEventWithPath(evt eventType, service *v1.Service, znodePath string) (*updaterEvent, error) {
+	data, err := u.renderServicePayload(service)
+	if err != nil {
+		return nil, err
+	}
+	return &updaterEvent{evt: evt, path: znodePath, data: data, service: service}, nil
+}
+
+// newPolicyUpdaterEvent is like newUpdaterEventWithPath but renders the
+// payload through policy's PayloadFields (falling back to the
+// configured --payload-template when PayloadFields is empty) and
+// carries policy's ACL, so announce writes the znode (and any missing
+// parents) with the policy's configured ACL instead of the World/PermAll
+// default. Per-pod child znodes from Endpoints events carry no ACL.
+func (u *Updater) newPolicyUpdaterEvent(evt eventType, service *v1.Service, znodePath string, policy *ZkAnnouncement) (*updaterEvent, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if len(policy.Spec.PayloadFields) > 0 {
+		data, err = renderFilteredPayload(service, nil, policy.Spec.PayloadFields)
+	} else {
+		data, err = u.renderServicePayload(service)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &updaterEvent{evt: evt, path: znodePath, data: data, service: service, acl: zkACLs(policy.Spec.ACL)}, nil
+}
+
+// newUpdaterEndpointsEvent flattens endpoints into the per-pod child
+// znode set that should exist under its Service's default path, ready
+// addresses as direct children and NotReadyAddresses under a "not-ready"
+// subtree.
+func newUpdaterEndpointsEvent(evt eventType, endpoints *v1.Endpoints) (*updaterEvent, error) {
+	servicePath := fmt.Sprintf("/services/%s/%s", endpoints.Namespace, endpoints.Name)
+
+	children := make(map[string][]byte)
+	for _, pe := range podEndpointsFromEndpoints(endpoints) {
+		data, err := json.Marshal(pe)
+		if err != nil {
+			return nil, err
+		}
+		childPath := znodeChildPath(servicePath, pe.IP, pe.Port)
+		if !pe.Ready {
+			childPath = notReadyZnodeChildPath(servicePath, pe.IP, pe.Port)
+		}
+		children[childPath] = data
+	}
+
+	return &updaterEvent{evt: evt, path: servicePath, children: children}, nil
+}
+
+// Run connects to ZooKeeper and processes events until stopCh is closed.
+func (u *Updater) Run(stopCh chan struct{}) {
+	conn, _, err := zk.Connect(strings.Split(u.zookeeperAddr, ","), 10*time.Second)
+	if err != nil {
+		log.Errorf("failed to connect to zookeeper %s: %v", u.zookeeperAddr, err)
+		return
+	}
+	u.conn = conn
+	defer conn.Close()
+
+	log.Info("Starting updater")
+	for {
+		select {
+		case event := <-u.events:
+			u.process(event)
+		case <-stopCh:
+			log.Info("Stopping updater")
+			return
+		}
+	}
+}
+
+func (u *Updater) process(event updaterEvent) {
+	switch event.evt {
+	case eventCreate, eventUpdate:
+		u.announce(event)
+	case eventDelete:
+		u.withdraw(event)
+	case eventEndpointsUpdate:
+		u.reconcileChildren(event)
+	case eventEndpointsDelete:
+		u.withdrawChildren(event)
+	}
+}
+
+func (u *Updater) announce(event updaterEvent) {
+	err := u.write(event.path, event.data, event.acl)
+	if err != nil {
+		log.Debugf("failed to announce %s: %v", event.path, err)
+	}
+	u.recordEvent(event.service, event.path, err, EventReasonAnnounced, EventReasonAnnounceFailed)
+}
+
+func (u *Updater) withdraw(event updaterEvent) {
+	err := u.delete(event.path)
+	if err != nil {
+		log.Debugf("failed to withdraw %s: %v", event.path, err)
+	}
+	u.recordEvent(event.service, event.path, err, EventReasonWithdrawn, EventReasonWithdrawFailed)
+}
+
+// recordEvent emits a Kubernetes Event against service reporting the
+// outcome of a ZK write, so operators without log access can see
+// whether a Service is actually reflected in ZooKeeper via `kubectl
+// describe service`. It is a no-op when no recorder is configured or
+// the event wasn't generated from a Service (e.g. endpoints events).
+func (u *Updater) recordEvent(service *v1.Service, znodePath string, err error, okReason, failReason string) {
+	if u.recorder == nil || service == nil {
+		return
+	}
+	if err != nil {
+		u.recorder.Eventf(service, v1.EventTypeWarning, failReason, "%s: %v", znodePath, err)
+		return
+	}
+	u.recorder.Eventf(service, v1.EventTypeNormal, okReason, "%s", znodePath)
+}
+
+// reconcileChildren writes every desired child znode under event.path
+// and prunes any existing child that is no longer desired, so stale
+// pods drop out as soon as their Endpoints entry disappears.
+func (u *Updater) reconcileChildren(event updaterEvent) {
+	for childPath, data := range event.children {
+		if err := u.writeEphemeral(childPath, data, event.acl); err != nil {
+			log.Debugf("failed to write child znode %s: %v", childPath, err)
+		}
+	}
+	u.pruneOrphans(event.path, event.children)
+}
+
+// pruneOrphans deletes every child currently under parentPath (in both
+// the ready and not-ready subtrees) that is not a key of desired.
+func (u *Updater) pruneOrphans(parentPath string, desired map[string][]byte) {
+	existing, err := u.listChildren(parentPath)
+	if err != nil {
+		log.Debugf("failed to list children of %s: %v", parentPath, err)
+		return
+	}
+	for _, childPath := range existing {
+		if _, ok := desired[childPath]; ok {
+			continue
+		}
+		if err := u.delete(childPath); err != nil {
+			log.Debugf("failed to prune orphaned znode %s: %v", childPath, err)
+		}
+	}
+}
+
+// withdrawChildren prunes every per-pod child znode under event.path
+// and removes the Service's root znode, as if the Service itself had
+// been deleted.
+func (u *Updater) withdrawChildren(event updaterEvent) {
+	u.pruneOrphans(event.path, nil)
+	if err := u.delete(path.Join(event.path, "not-ready")); err != nil {
+		log.Debugf("failed to withdraw %s/not-ready: %v", event.path, err)
+	}
+	if err := u.delete(event.path); err != nil {
+		log.Debugf("failed to withdraw %s: %v", event.path, err)
+	}
+}
+
+// listChildren returns the full znode paths of every child under
+// parentPath, descending into the "not-ready" subtree if present.
+func (u *Updater) listChildren(parentPath string) ([]string, error) {
+	names, _, err := u.conn.Children(parentPath)
+	if err != nil {
+		if err == zk.ErrNoNode {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var all []string
+	for _, name := range names {
+		if name != "not-ready" {
+			all = append(all, path.Join(parentPath, name))
+			continue
+		}
+		notReadyPath := path.Join(parentPath, "not-ready")
+		notReadyNames, _, err := u.conn.Children(notReadyPath)
+		if err != nil {
+			if err == zk.ErrNoNode {
+				continue
+			}
+			return nil, err
+		}
+		for _, nr := range notReadyNames {
+			all = append(all, path.Join(notReadyPath, nr))
+		}
+	}
+	return all, nil
+}
+
+// write creates or updates a persistent znode at znodePath, creating any
+// missing parent directories first. acl defaults to
+// zk.WorldACL(zk.PermAll) when nil.
+func (u *Updater) write(znodePath string, data []byte, acl []zk.ACL) error {
+	if err := u.ensurePath(path.Dir(znodePath), acl); err != nil {
+		return err
+	}
+	return u.set(znodePath, data, 0, acl)
+}
+
+// writeEphemeral is like write but the leaf znode is created with
+// zk.FlagEphemeral, so a per-pod entry disappears automatically if this
+// process dies without withdrawing it.
+func (u *Updater) writeEphemeral(znodePath string, data []byte, acl []zk.ACL) error {
+	if err := u.ensurePath(path.Dir(znodePath), acl); err != nil {
+		return err
+	}
+	return u.set(znodePath, data, zk.FlagEphemeral, acl)
+}
+
+func (u *Updater) set(znodePath string, data []byte, flags int32, acl []zk.ACL) error {
+	if acl == nil {
+		acl = zk.WorldACL(zk.PermAll)
+	}
+
+	exists, stat, err := u.conn.Exists(znodePath)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		_, err := u.conn.Create(znodePath, data, flags, acl)
+		return err
+	}
+	_, err = u.conn.Set(znodePath, data, stat.Version)
+	return err
+}
+
+// ensurePath creates every missing persistent parent segment of
+// znodePath, so a leaf znode can be written under it. acl defaults to
+// zk.WorldACL(zk.PermAll) when nil.
+func (u *Updater) ensurePath(znodePath string, acl []zk.ACL) error {
+	if znodePath == "" || znodePath == "/" || znodePath == "." {
+		return nil
+	}
+	if acl == nil {
+		acl = zk.WorldACL(zk.PermAll)
+	}
+
+	exists, _, err := u.conn.Exists(znodePath)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	if err := u.ensurePath(path.Dir(znodePath), acl); err != nil {
+		return err
+	}
+	if _, err := u.conn.Create(znodePath, nil, 0, acl); err != nil && err != zk.ErrNodeExists {
+		return err
+	}
+	return nil
+}
+
+// delete removes znodePath if it exists; deleting an absent znode is a
+// no-op so callers don't need to special-case "already withdrawn".
+func (u *Updater) delete(znodePath string) error {
+	exists, stat, err := u.conn.Exists(znodePath)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return nil
+	}
+	return u.conn.Delete(znodePath, stat.Version)
+}
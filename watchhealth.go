@@ -0,0 +1,41 @@
+package main
+
+import (
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// watchHealth tracks whether a serviceController's Service watch is
+// actually succeeding. cache.Controller.HasSynced() can't tell us this:
+// it only reports the initial list sync and stays true forever
+// afterward even while the reflector's ListAndWatch silently retries a
+// dead connection in the background. wrapWatchFunc records the outcome
+// of every watch attempt the reflector makes, so a caller can tell a
+// genuinely dead watch (every reconnect attempt erroring) apart from
+// one that is merely idle between events.
+type watchHealth struct {
+	mu      sync.Mutex
+	lastErr error
+}
+
+// wrapWatchFunc wraps a cache.ListWatch WatchFunc to record the outcome
+// of every call the reflector makes to it.
+func (w *watchHealth) wrapWatchFunc(fn func(metav1.ListOptions) (watch.Interface, error)) func(metav1.ListOptions) (watch.Interface, error) {
+	return func(lo metav1.ListOptions) (watch.Interface, error) {
+		watcher, err := fn(lo)
+		w.mu.Lock()
+		w.lastErr = err
+		w.mu.Unlock()
+		return watcher, err
+	}
+}
+
+// Err reports the error from the most recent watch attempt, or nil if
+// it succeeded (or none has been made yet).
+func (w *watchHealth) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.lastErr
+}
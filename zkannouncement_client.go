@@ -0,0 +1,29 @@
+package main
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+// newZkAnnouncementClient builds a REST client scoped to the
+// ZkAnnouncement CRD's group/version, the same approach used by
+// generated clientsets before code-gen is wired up for this type.
+func newZkAnnouncementClient(cfg *rest.Config) (rest.Interface, error) {
+	config := *cfg
+	config.GroupVersion = &SchemeGroupVersion
+	config.APIPath = "/apis"
+	config.ContentType = runtime.ContentTypeJSON
+
+	s := runtime.NewScheme()
+	if err := AddToScheme(s); err != nil {
+		return nil, err
+	}
+	if err := scheme.AddToScheme(s); err != nil {
+		return nil, err
+	}
+	config.NegotiatedSerializer = serializer.NewCodecFactory(s).WithoutConversion()
+
+	return rest.RESTClientFor(&config)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ZkAnnouncementLister lists ZkAnnouncement objects out of a shared
+// indexer, mirroring the generated lister_v1.ServiceLister pattern.
+type ZkAnnouncementLister interface {
+	ZkAnnouncements(namespace string) ZkAnnouncementNamespaceLister
+}
+
+// ZkAnnouncementNamespaceLister lists ZkAnnouncement objects in a
+// single namespace.
+type ZkAnnouncementNamespaceLister interface {
+	List(selector labels.Selector) ([]*ZkAnnouncement, error)
+}
+
+type zkAnnouncementLister struct {
+	indexer cache.Indexer
+}
+
+// NewZkAnnouncementLister returns a ZkAnnouncementLister backed by indexer.
+func NewZkAnnouncementLister(indexer cache.Indexer) ZkAnnouncementLister {
+	return &zkAnnouncementLister{indexer: indexer}
+}
+
+func (l *zkAnnouncementLister) ZkAnnouncements(namespace string) ZkAnnouncementNamespaceLister {
+	return &zkAnnouncementNamespaceLister{indexer: l.indexer, namespace: namespace}
+}
+
+type zkAnnouncementNamespaceLister struct {
+	indexer   cache.Indexer
+	namespace string
+}
+
+func (l *zkAnnouncementNamespaceLister) List(selector labels.Selector) ([]*ZkAnnouncement, error) {
+	var result []*ZkAnnouncement
+	err := cache.ListAllByNamespace(l.indexer, l.namespace, selector, func(m interface{}) {
+		result = append(result, m.(*ZkAnnouncement))
+	})
+	return result, err
+}
+
+func newZkAnnouncementIndexerInformer(client rest.Interface, namespace string, resyncPeriod time.Duration, handlers cache.ResourceEventHandlerFuncs) (cache.Indexer, cache.Controller) {
+	return cache.NewIndexerInformer(
+		&cache.ListWatch{
+			ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+				result := &ZkAnnouncementList{}
+				err := client.Get().
+					Namespace(namespace).
+					Resource("zkannouncements").
+					VersionedParams(&lo, metav1.ParameterCodec).
+					Do().
+					Into(result)
+				return result, err
+			},
+			WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+				lo.Watch = true
+				return client.Get().
+					Namespace(namespace).
+					Resource("zkannouncements").
+					VersionedParams(&lo, metav1.ParameterCodec).
+					Watch()
+			},
+		},
+		&ZkAnnouncement{},
+		resyncPeriod,
+		handlers,
+		cache.Indexers{},
+	)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	zkAnnouncementGroup   = "zk-announser.frimik.github.com"
+	zkAnnouncementVersion = "v1alpha1"
+)
+
+// SchemeGroupVersion is the group/version used to register ZkAnnouncement
+// with a runtime.Scheme.
+var SchemeGroupVersion = schema.GroupVersion{Group: zkAnnouncementGroup, Version: zkAnnouncementVersion}
+
+func resource(resource string) schema.GroupResource {
+	return SchemeGroupVersion.WithResource(resource).GroupResource()
+}
+
+// AddToScheme registers the ZkAnnouncement types with the given scheme,
+// following the same pattern as client-go's generated clientsets.
+func AddToScheme(scheme *runtime.Scheme) error {
+	return addKnownTypes(scheme)
+}
+
+func addKnownTypes(scheme *runtime.Scheme) error {
+	scheme.AddKnownTypes(SchemeGroupVersion,
+		&ZkAnnouncement{},
+		&ZkAnnouncementList{},
+	)
+	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ZkAnnouncement is a namespaced policy describing which Services get
+// announced into ZooKeeper, under what path, with what payload shape,
+// and with what ACLs.
+//
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type ZkAnnouncement struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ZkAnnouncementSpec   `json:"spec"`
+	Status ZkAnnouncementStatus `json:"status,omitempty"`
+}
+
+// ZkAnnouncementSpec selects the Services this policy applies to and
+// describes how they should be rendered into ZooKeeper.
+type ZkAnnouncementSpec struct {
+	// Selector matches Services in the same namespace as the policy.
+	Selector metav1.LabelSelector `json:"selector"`
+
+	// PathTemplate is a Go text/template rendered against the Service,
+	// e.g. "/discovery/{{.Namespace}}/{{.Name}}".
+	PathTemplate string `json:"pathTemplate"`
+
+	// PayloadFields lists the JSON fields (payloadTemplateData's json
+	// keys) to include in the znode payload. Values are taken from the
+	// Service only; the "endpoints" field is always rendered as null.
+	PayloadFields []string `json:"payloadFields,omitempty"`
+
+	// ACL lists the ZooKeeper ACLs to apply to znodes written under
+	// PathTemplate for this policy.
+	ACL []ZkACL `json:"acl,omitempty"`
+}
+
+// ZkACL mirrors the subset of go-zookeeper's ACL struct that is useful
+// to express in a CRD spec.
+type ZkACL struct {
+	Scheme string `json:"scheme"`
+	ID     string `json:"id"`
+	Perms  int32  `json:"perms"`
+}
+
+// ZkAnnouncementStatus reports the effect of a policy, updated by the
+// serviceController each time it announces a Service the policy matches.
+type ZkAnnouncementStatus struct {
+	// AnnouncedServices is the number of Services currently matched and
+	// announced by this policy.
+	AnnouncedServices int32 `json:"announcedServices"`
+
+	// LastSyncTime is when the controller last reconciled this policy.
+	LastSyncTime metav1.Time `json:"lastSyncTime,omitempty"`
+}
+
+// ZkAnnouncementList is a list of ZkAnnouncement resources.
+//
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type ZkAnnouncementList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []ZkAnnouncement `json:"items"`
+}
+
+// DeepCopyObject implements runtime.Object.
+func (in *ZkAnnouncement) DeepCopyObject() runtime.Object {
+	out := new(ZkAnnouncement)
+	*out = *in
+	out.ObjectMeta = *in.ObjectMeta.DeepCopy()
+	out.Spec.Selector = *in.Spec.Selector.DeepCopy()
+	if in.Spec.PayloadFields != nil {
+		out.Spec.PayloadFields = append([]string(nil), in.Spec.PayloadFields...)
+	}
+	if in.Spec.ACL != nil {
+		out.Spec.ACL = append([]ZkACL(nil), in.Spec.ACL...)
+	}
+	return out
+}
+
+// DeepCopyObject implements runtime.Object.
+func (in *ZkAnnouncementList) DeepCopyObject() runtime.Object {
+	out := new(ZkAnnouncementList)
+	*out = *in
+	out.Items = make([]ZkAnnouncement, len(in.Items))
+	for i := range in.Items {
+		out.Items[i] = *in.Items[i].DeepCopyObject().(*ZkAnnouncement)
+	}
+	return out
+}